@@ -0,0 +1,122 @@
+// Package elevated runs a command on a remote Windows host with
+// elevated privileges by registering it as a scheduled task, then
+// streams the task's output back to the caller's packer.Ui as it is
+// produced.
+//
+// This mirrors the approach packer's own provisioners use to work
+// around WinRM's double-hop / UAC restrictions: a scheduled task run
+// as a logged-on user (or SYSTEM) is not subject to them, even though
+// a plain elevated command over the communicator often is.
+package elevated
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// pollInterval is how often the remote output log is polled while an
+// elevated task is running.
+const pollInterval = 2 * time.Second
+
+// Options configures a single elevated command execution.
+type Options struct {
+	// Username/Password of the account the scheduled task runs as.
+	Username string
+	Password string
+
+	// TaskName must be unique per invocation. It is used as the
+	// scheduled task name and to derive the remote wrapper script and
+	// log file paths.
+	TaskName        string
+	TaskDescription string
+
+	// Command is the command line the scheduled task runs.
+	Command string
+}
+
+func (o Options) remotePath(suffix string) string {
+	return fmt.Sprintf("C:/Windows/Temp/%s.%s", o.TaskName, suffix)
+}
+
+// Run registers opts.Command as a scheduled task so it executes with
+// elevated privileges, streaming its output to ui as it is produced,
+// and returns its exit code once the task completes.
+func Run(ctx context.Context, comm packer.Communicator, ui packer.Ui, opts Options) (int, error) {
+	wrapperPath := opts.remotePath("ps1")
+	logPath := opts.remotePath("log")
+
+	var buffer bytes.Buffer
+	if err := wrapperTemplate.Execute(&buffer, wrapperData{
+		Options: opts,
+		LogPath: logPath,
+	}); err != nil {
+		return 0, fmt.Errorf("failed to render elevated wrapper script: %s", err)
+	}
+
+	if err := comm.Upload(wrapperPath, bytes.NewReader(buffer.Bytes()), nil); err != nil {
+		return 0, err
+	}
+
+	cmd := &packer.RemoteCmd{
+		Command: fmt.Sprintf("PowerShell -ExecutionPolicy Bypass -OutputFormat Text -File %s", wrapperPath),
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		return 0, err
+	}
+
+	tailCtx, stopTail := context.WithCancel(ctx)
+	offsetCh := make(chan int, 1)
+	go func() {
+		offsetCh <- tailLog(tailCtx, comm, ui, logPath)
+	}()
+
+	cmd.Wait()
+	stopTail()
+	offset := <-offsetCh
+
+	// One last read to pick up anything written between the final
+	// poll and the task actually exiting.
+	readLogSince(comm, ui, logPath, offset)
+
+	return cmd.ExitStatus(), nil
+}
+
+// tailLog polls logPath every pollInterval and writes any newly
+// appeared output to ui until ctx is cancelled, returning the offset
+// it last read up to.
+func tailLog(ctx context.Context, comm packer.Communicator, ui packer.Ui, logPath string) int {
+	var offset int
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return offset
+		case <-ticker.C:
+			offset = readLogSince(comm, ui, logPath, offset)
+		}
+	}
+}
+
+// readLogSince downloads logPath and prints any bytes past offset to
+// ui, returning the new offset. Download errors are swallowed: the
+// log file may not exist yet if the scheduled task hasn't started.
+func readLogSince(comm packer.Communicator, ui packer.Ui, logPath string, offset int) int {
+	var buffer bytes.Buffer
+	if err := comm.Download(logPath, &buffer); err != nil {
+		return offset
+	}
+
+	contents := buffer.Bytes()
+	if len(contents) <= offset {
+		return offset
+	}
+
+	ui.Say(string(contents[offset:]))
+	return len(contents)
+}
@@ -0,0 +1,56 @@
+package elevated
+
+import (
+	"strings"
+	"text/template"
+)
+
+type wrapperData struct {
+	Options
+	LogPath string
+}
+
+// escapePowerShellDoubleQuotedString escapes value for safe interpolation
+// inside a double-quoted PowerShell string, where the backtick is the
+// escape character and both the backtick itself and the double quote
+// must be escaped. This is distinct from update.escapePowerShellString,
+// which only handles single-quoted strings, and can't be reused here
+// since update/elevated is imported by the update package.
+func escapePowerShellDoubleQuotedString(value string) string {
+	replacer := strings.NewReplacer(
+		"`", "``",
+		"\"", "`\"",
+		"$", "`$",
+	)
+	return replacer.Replace(value)
+}
+
+// wrapperTemplate renders the PowerShell script uploaded and run to
+// register, start and await opts.Command as a scheduled task,
+// redirecting its output to LogPath so Run can tail it. Username,
+// Password and TaskDescription are passed through dq since they are
+// interpolated into double-quoted strings and may contain arbitrary
+// characters.
+var wrapperTemplate = template.Must(template.New("elevated-wrapper").Funcs(template.FuncMap{
+	"dq": escapePowerShellDoubleQuotedString,
+}).Parse(`
+$ErrorActionPreference = "Stop"
+
+$taskName = "{{.TaskName | dq}}"
+$action = New-ScheduledTaskAction -Execute "cmd.exe" -Argument '/c {{.Command}} > "{{.LogPath}}" 2>&1'
+$principal = New-ScheduledTaskPrincipal -UserId "{{.Username | dq}}" -LogonType Password -RunLevel Highest
+$settings = New-ScheduledTaskSettingsSet -AllowStartIfOnBatteries -DontStopIfGoingOnBatteries -ExecutionTimeLimit ([TimeSpan]::Zero)
+$task = New-ScheduledTask -Action $action -Principal $principal -Settings $settings -Description "{{.TaskDescription | dq}}"
+
+Register-ScheduledTask -TaskName $taskName -InputObject $task -User "{{.Username | dq}}" -Password "{{.Password | dq}}" -Force | Out-Null
+Start-ScheduledTask -TaskName $taskName
+
+while ((Get-ScheduledTask -TaskName $taskName -ErrorAction SilentlyContinue).State -eq 'Running') {
+    Start-Sleep -Seconds 1
+}
+
+$info = Get-ScheduledTaskInfo -TaskName $taskName
+Unregister-ScheduledTask -TaskName $taskName -Confirm:$false
+
+exit $info.LastTaskResult
+`))
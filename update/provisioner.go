@@ -13,23 +13,22 @@ import (
 	"unicode/utf16"
 
 	"github.com/hashicorp/packer/common"
-	"github.com/hashicorp/packer/common/retry"
 	"github.com/hashicorp/packer/common/uuid"
 	"github.com/hashicorp/packer/helper/config"
 	"github.com/hashicorp/packer/packer"
 	"github.com/hashicorp/packer/template/interpolate"
+
+	"github.com/ncelebic/packer-provisioner-windows-update/update/elevated"
+	"github.com/ncelebic/packer-provisioner-windows-update/update/report"
+	"github.com/ncelebic/packer-provisioner-windows-update/update/retry"
 )
 
 const (
-	elevatedPath                 = "C:/Windows/Temp/packer-windows-update-elevated.ps1"
-	elevatedCommand              = "PowerShell -ExecutionPolicy Bypass -OutputFormat Text -File C:/Windows/Temp/packer-windows-update-elevated.ps1"
-	windowsUpdatePath            = "C:/Windows/Temp/packer-windows-update.ps1"
-	pendingRebootElevatedPath    = "C:/Windows/Temp/packer-windows-update-pending-reboot-elevated.ps1"
-	pendingRebootElevatedCommand = "PowerShell -ExecutionPolicy Bypass -OutputFormat Text -File C:/Windows/Temp/packer-windows-update-pending-reboot-elevated.ps1"
-	restartCommand               = "shutdown.exe -f -r -t 0 -c \"packer restart\""
-	testRestartCommand           = "shutdown.exe -f -r -t 60 -c \"packer restart test\""
-	abortTestRestartCommand      = "shutdown.exe -a"
-	retryableDelay               = 5 * time.Second
+	windowsUpdatePath       = "C:/Windows/Temp/packer-windows-update.ps1"
+	reportRemotePath        = "C:/Windows/Temp/packer-windows-update-report.json"
+	restartCommand          = "shutdown.exe -f -r -t 0 -c \"packer restart\""
+	testRestartCommand      = "shutdown.exe -f -r -t 60 -c \"packer restart test\""
+	abortTestRestartCommand = "shutdown.exe -a"
 )
 
 type Config struct {
@@ -55,11 +54,104 @@ type Config struct {
 	// Adds a limit to how many updates are installed at a time
 	UpdateLimit int `mapstructure:"update_limit"`
 
+	// Local path to write the aggregated update report to. If empty,
+	// no report is written.
+	ReportPath string `mapstructure:"report_path"`
+
+	// Format of the report written to ReportPath. One of "json",
+	// "csv" or "sarif". Defaults to "json".
+	ReportFormat string `mapstructure:"report_format"`
+
+	// The internal WSUS server to report updates to, e.g.
+	// "http://wsus.example.com:8530". If set, the provisioner points
+	// Windows Update at it instead of the public Microsoft Update
+	// endpoints for the duration of the provision, restoring the
+	// previous settings afterwards.
+	WSUSServer string `mapstructure:"wsus_server"`
+
+	// The WSUS server to report update status to. Defaults to
+	// WSUSServer if not set.
+	WSUSStatusServer string `mapstructure:"wsus_status_server"`
+
+	// The WSUS target group to report this machine as a member of.
+	WSUSTargetGroup string `mapstructure:"wsus_target_group"`
+
+	// If true, sets the DisableWindowsUpdateAccess policy value to 0
+	// while the WSUS policy is applied. It only takes effect when
+	// WSUSServer or DisableWindowsUpdateAccess is set, and
+	// DisableWindowsUpdateAccess takes precedence over it. No other
+	// policy value is written for it.
+	UseMicrosoftUpdate bool `mapstructure:"use_microsoft_update"`
+
+	// Disable all access to Windows Update, including WSUS. Useful in
+	// air-gapped environments where updates are applied some other
+	// way and Windows Update should not be allowed to search at all.
+	DisableWindowsUpdateAccess bool `mapstructure:"disable_windows_update_access"`
+
+	// If true, the provisioner fails the build when any individual
+	// update reports a non-successful result code, even if the update
+	// script itself exits 0 or 101. By default such failures are only
+	// surfaced in the report so the build can be gated on it later.
+	FailOnUpdateError bool `mapstructure:"fail_on_update_error"`
+
+	// If true, the provisioner snapshots the machine before each
+	// update batch and, if a batch hard-fails, reverts to the
+	// snapshot, adds the offending KBs to the skip list, and retries
+	// the remaining updates.
+	RollbackOnFailure bool `mapstructure:"rollback_on_failure"`
+
+	// How to snapshot and revert the machine when RollbackOnFailure
+	// is set. One of "system-restore" (the default, uses the guest's
+	// own System Restore) or "hyperv" (uses a Hyper-V checkpoint on
+	// the host running the build, via HyperVVMName).
+	RollbackProvider string `mapstructure:"rollback_provider"`
+
+	// The name of the Hyper-V VM to checkpoint, required when
+	// RollbackProvider is "hyperv".
+	HyperVVMName string `mapstructure:"hyperv_vm_name"`
+
+	// KB IDs (e.g. "KB5001330") to never install. Seeds the skip list
+	// that RollbackOnFailure also appends to as updates are found to
+	// break the machine.
+	SkipKBs []string `mapstructure:"skip_kbs"`
+
+	// If true (the default), windows-update.ps1 is verified against
+	// the bundled release key and any TrustedPubKeys before it is
+	// uploaded, and its SHA-256 hash is
+	// re-checked on the remote host afterwards. A pointer so the
+	// default can be "true" while still letting operators opt out.
+	RequireSignedScripts *bool `mapstructure:"require_signed_scripts"`
+
+	// Additional hex-encoded Ed25519 public keys trusted to sign
+	// windows-update.ps1, on top of the key bundled with the
+	// provisioner. Lets operators rotate the release signing key
+	// without upgrading.
+	TrustedPubKeys []string `mapstructure:"trusted_pubkeys"`
+
+	// Backoff policy used while waiting for the machine to restart.
+	// Defaults to a 5s initial delay, doubling up to 30s, with 20%
+	// jitter.
+	RestartBackoffInitial    time.Duration `mapstructure:"restart_backoff_initial"`
+	RestartBackoffMax        time.Duration `mapstructure:"restart_backoff_max"`
+	RestartBackoffMultiplier float64       `mapstructure:"restart_backoff_multiplier"`
+	RestartBackoffJitter     float64       `mapstructure:"restart_backoff_jitter"`
+
+	// Command run on the remote host to decide whether it has
+	// finished restarting and is ready to continue. Defaults to the
+	// shutdown.exe test-and-abort dance this provisioner has always
+	// used; override it if that doesn't work reliably on your
+	// platform (e.g. some Azure/GCP images).
+	RestartCheckCommand string `mapstructure:"restart_check_command"`
+
 	ctx interpolate.Context
 }
 
 type Provisioner struct {
 	config Config
+
+	// skipKBs starts as a copy of config.SkipKBs and grows as
+	// RollbackOnFailure identifies updates that need to be skipped.
+	skipKBs []string
 }
 
 func (p *Provisioner) Prepare(raws ...interface{}) error {
@@ -95,69 +187,103 @@ func (p *Provisioner) Prepare(raws ...interface{}) error {
 		p.config.UpdateLimit = 1000
 	}
 
+	if p.config.ReportFormat == "" {
+		p.config.ReportFormat = "json"
+	}
+
+	switch p.config.ReportFormat {
+	case "json", "csv", "sarif":
+	default:
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("report_format must be one of 'json', 'csv' or 'sarif', got %q", p.config.ReportFormat))
+	}
+
+	if p.config.RestartBackoffInitial == 0 {
+		p.config.RestartBackoffInitial = 5 * time.Second
+	}
+
+	if p.config.RestartBackoffMax == 0 {
+		p.config.RestartBackoffMax = 30 * time.Second
+	}
+
+	if p.config.RestartBackoffMultiplier == 0 {
+		p.config.RestartBackoffMultiplier = 2
+	}
+
+	if p.config.RestartBackoffJitter == 0 {
+		p.config.RestartBackoffJitter = 0.2
+	}
+
+	if p.config.RollbackProvider == "" {
+		p.config.RollbackProvider = "system-restore"
+	}
+
+	switch p.config.RollbackProvider {
+	case "system-restore":
+	case "hyperv":
+		if p.config.HyperVVMName == "" {
+			errs = packer.MultiErrorAppend(errs,
+				errors.New("Must supply a 'hyperv_vm_name' when 'rollback_provider' is 'hyperv'"))
+		}
+	default:
+		errs = packer.MultiErrorAppend(errs,
+			fmt.Errorf("rollback_provider must be 'system-restore' or 'hyperv', got %q", p.config.RollbackProvider))
+	}
+
 	return errs
 }
 
 func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
-	ui.Say("Uploading the Windows update elevated script...")
-	var buffer bytes.Buffer
-	err := elevatedTemplate.Execute(&buffer, elevatedOptions{
-		Username:        p.config.Username,
-		Password:        p.config.Password,
-		TaskDescription: "Packer Windows update elevated task",
-		TaskName:        fmt.Sprintf("packer-windows-update-%s", uuid.TimeOrderedUUID()),
-		Command:         p.windowsUpdateCommand(),
-	})
-	if err != nil {
-		fmt.Printf("Error creating elevated template: %s", err)
-		return err
-	}
-	err = comm.Upload(
-		elevatedPath,
-		bytes.NewReader(buffer.Bytes()),
-		nil)
-	if err != nil {
-		return err
+	if p.wsusConfigured() {
+		// Arm the restore before configuring: configureWSUS's elevated
+		// script can write the backup and apply some policy values
+		// before failing partway through, and restoreWSUS leaves the
+		// policy values alone if no backup was written yet (it only
+		// restarts wuauserv), so it's always safe to attempt.
+		defer func() {
+			if err := p.restoreWSUS(ctx, ui, comm); err != nil {
+				ui.Say(fmt.Sprintf("Failed to restore previous Windows Update settings: %s", err))
+			}
+		}()
+		if err := p.configureWSUS(ctx, ui, comm); err != nil {
+			return err
+		}
 	}
 
-	ui.Say("Uploading the Windows update check for reboot required elevated script...")
-	buffer.Reset()
-	err = elevatedTemplate.Execute(&buffer, elevatedOptions{
-		Username:        p.config.Username,
-		Password:        p.config.Password,
-		TaskDescription: "Packer Windows update pending reboot elevated task",
-		TaskName:        fmt.Sprintf("packer-windows-update-pending-reboot-%s", uuid.TimeOrderedUUID()),
-		Command:         p.windowsUpdateCheckForRebootRequiredCommand(),
-	})
-	if err != nil {
-		fmt.Printf("Error creating elevated template: %s", err)
-		return err
-	}
-	err = comm.Upload(
-		pendingRebootElevatedPath,
-		bytes.NewReader(buffer.Bytes()),
-		nil)
-	if err != nil {
-		return err
+	script := MustAsset("windows-update.ps1")
+
+	if p.requireSignedScripts() {
+		if err := p.verifyScript(script); err != nil {
+			return fmt.Errorf("windows-update.ps1 failed signature verification: %s", err)
+		}
 	}
 
 	ui.Say("Uploading the Windows update script...")
-	err = comm.Upload(
+	err := comm.Upload(
 		windowsUpdatePath,
-		bytes.NewReader(MustAsset("windows-update.ps1")),
+		bytes.NewReader(script),
 		nil)
 	if err != nil {
 		return err
 	}
 
+	if p.requireSignedScripts() {
+		if err := p.verifyRemoteHash(ctx, ui, comm, windowsUpdatePath, script); err != nil {
+			return err
+		}
+	}
+
+	p.skipKBs = append([]string(nil), p.config.SkipKBs...)
+	rep := &report.Report{}
+
 	for {
-		restartPending, err := p.update(ctx, ui, comm)
+		restartPending, err := p.updateBatch(ctx, ui, comm, rep)
 		if err != nil {
 			return err
 		}
 
 		if !restartPending {
-			return nil
+			return p.writeReport(ui, rep)
 		}
 
 		err = p.restart(ctx, ui, comm)
@@ -167,27 +293,125 @@ func (p *Provisioner) Provision(ctx context.Context, ui packer.Ui, comm packer.C
 	}
 }
 
-func (p *Provisioner) update(ctx context.Context, ui packer.Ui, comm packer.Communicator) (bool, error) {
+// updateBatch runs a single update() cycle. If RollbackOnFailure is
+// set and the cycle hard-fails (either a specific update hard-failed,
+// or windows-update.ps1 itself exited with an unexpected status), it
+// reverts to the checkpoint taken before the cycle, adds any offending
+// KBs to the skip list, and retries.
+func (p *Provisioner) updateBatch(ctx context.Context, ui packer.Ui, comm packer.Communicator, rep *report.Report) (bool, error) {
+	for attempt := 0; ; attempt++ {
+		batchID := uuid.TimeOrderedUUID()
+
+		if err := p.checkpoint(ctx, ui, comm, batchID); err != nil {
+			return false, err
+		}
+
+		restartPending, badKBs, err := p.update(ctx, ui, comm, rep, batchID)
+		if err == nil && len(badKBs) == 0 {
+			return restartPending, nil
+		}
+
+		if !p.config.RollbackOnFailure || attempt >= maxRollbackRetries {
+			if err != nil {
+				return false, err
+			}
+			return restartPending, nil
+		}
+
+		if len(badKBs) > 0 {
+			ui.Say(fmt.Sprintf("Update batch failed, rolling back and skipping: %s", strings.Join(badKBs, ", ")))
+		} else {
+			ui.Say(fmt.Sprintf("Update batch failed, rolling back: %s", err))
+		}
+		if revertErr := p.revert(ctx, ui, comm, batchID); revertErr != nil {
+			if err != nil {
+				return false, fmt.Errorf("update failed (%s) and rollback failed: %s", err, revertErr)
+			}
+			return false, revertErr
+		}
+
+		if err := p.waitForAvailable(ctx, ui, comm); err != nil {
+			return false, fmt.Errorf("rollback succeeded but machine did not become available again: %s", err)
+		}
+
+		p.skipKBs = append(p.skipKBs, badKBs...)
+	}
+}
+
+func (p *Provisioner) update(ctx context.Context, ui packer.Ui, comm packer.Communicator, rep *report.Report, batchID string) (bool, []string, error) {
 	ui.Say("Running Windows update...")
-	cmd := &packer.RemoteCmd{Command: elevatedCommand}
-	err := cmd.RunWithUi(ctx, comm, ui)
+	exitStatus, err := elevated.Run(ctx, comm, ui, elevated.Options{
+		Username:        p.config.Username,
+		Password:        p.config.Password,
+		TaskDescription: "Packer Windows update elevated task",
+		TaskName:        fmt.Sprintf("packer-windows-update-%s", batchID),
+		Command:         p.windowsUpdateCommand(),
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	badKBs, err := p.collectReport(ctx, ui, comm, rep)
 	if err != nil {
-		return false, err
+		return false, badKBs, err
 	}
-	var exitStatus = cmd.ExitStatus()
+
 	switch exitStatus {
 	case 0:
-		return false, nil
+		return false, badKBs, nil
 	case 101:
-		return true, nil
+		return true, badKBs, nil
 	default:
-		return false, fmt.Errorf("Windows update script exited with non-zero exit status: %d", exitStatus)
+		return false, badKBs, fmt.Errorf("Windows update script exited with non-zero exit status: %d", exitStatus)
+	}
+}
+
+// collectReport downloads the per-cycle report windows-update.ps1
+// writes to reportRemotePath, merges it into rep, and returns the KBs
+// in this cycle that hard-failed (as opposed to merely needing a
+// reboot). It fails the build if FailOnUpdateError is set and any
+// update in the cycle failed.
+func (p *Provisioner) collectReport(ctx context.Context, ui packer.Ui, comm packer.Communicator, rep *report.Report) ([]string, error) {
+	var buffer bytes.Buffer
+	if err := comm.Download(reportRemotePath, &buffer); err != nil {
+		ui.Say(fmt.Sprintf("Could not download Windows update report: %s", err))
+		return nil, nil
+	}
+
+	cycle, err := report.ParseCycle(&buffer)
+	if err != nil {
+		return nil, err
+	}
+	rep.Append(cycle)
+
+	var badKBs []string
+	for _, r := range cycle.FailedWithoutReboot() {
+		badKBs = append(badKBs, r.KBID)
+	}
+
+	if failed := cycle.Failed(); p.config.FailOnUpdateError && len(failed) > 0 {
+		return badKBs, fmt.Errorf("%d Windows update(s) failed to install, first failure: %s (result code %d)", len(failed), failed[0].KBID, failed[0].ResultCode)
+	}
+
+	return badKBs, nil
+}
+
+// writeReport renders the aggregated report to p.config.ReportPath, if
+// configured.
+func (p *Provisioner) writeReport(ui packer.Ui, rep *report.Report) error {
+	if p.config.ReportPath == "" {
+		return nil
 	}
+
+	ui.Say(fmt.Sprintf("Writing Windows update report to %s...", p.config.ReportPath))
+	return report.WriteFile(rep, p.config.ReportPath, p.config.ReportFormat)
 }
 
 func (p *Provisioner) restart(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	backoff := p.restartBackoff()
+
 	ui.Say("Restarting the machine...")
-	err := p.retryable(ctx, func(ctx context.Context) error {
+	err := backoff.Run(ctx, ui, p.config.RestartTimeout, func(ctx context.Context) error {
 		cmd := &packer.RemoteCmd{Command: restartCommand}
 		err := cmd.RunWithUi(ctx, comm, ui)
 		if err != nil {
@@ -203,69 +427,104 @@ func (p *Provisioner) restart(ctx context.Context, ui packer.Ui, comm packer.Com
 		return err
 	}
 
+	return p.waitForAvailable(ctx, ui, comm)
+}
+
+// waitForAvailable waits for comm to regain connectivity and for any
+// pending reboot to finish. It is used after any operation that
+// reboots or resets the machine out from under comm: a restart, or a
+// rollback revert (System Restore and Hyper-V snapshots both reset
+// the machine the same way a restart does).
+func (p *Provisioner) waitForAvailable(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	backoff := p.restartBackoff()
+
 	ui.Say("Waiting for machine to become available...")
-	err = p.retryable(ctx, func(ctx context.Context) error {
-		// wait for the machine to reboot.
-		cmd := &packer.RemoteCmd{Command: testRestartCommand}
-		err := cmd.RunWithUi(ctx, comm, ui)
-		if err != nil {
-			return err
-		}
-		exitStatus := cmd.ExitStatus()
-		if exitStatus != 0 {
-			return fmt.Errorf("Machine not yet available (exit status %d)", exitStatus)
-		}
-		cmd = &packer.RemoteCmd{Command: abortTestRestartCommand}
-		err = cmd.RunWithUi(ctx, comm, ui)
-		if err != nil {
+	return backoff.Run(ctx, ui, p.config.RestartTimeout, func(ctx context.Context) error {
+		if err := p.checkRestartComplete(ctx, ui, comm); err != nil {
 			return err
 		}
 
 		// wait for pending tasks to finish.
-		cmd = &packer.RemoteCmd{Command: pendingRebootElevatedCommand}
-		err = cmd.RunWithUi(ctx, comm, ui)
+		rebootExitStatus, err := elevated.Run(ctx, comm, ui, elevated.Options{
+			Username:        p.config.Username,
+			Password:        p.config.Password,
+			TaskDescription: "Packer Windows update pending reboot elevated task",
+			TaskName:        fmt.Sprintf("packer-windows-update-pending-reboot-%s", uuid.TimeOrderedUUID()),
+			Command:         p.windowsUpdateCheckForRebootRequiredCommand(),
+		})
 		if err != nil {
 			return err
 		}
-		exitStatus = cmd.ExitStatus()
-		if exitStatus != 0 {
-			return fmt.Errorf("Machine not yet available (exit status %d)", exitStatus)
+		if rebootExitStatus != 0 {
+			return fmt.Errorf("Machine not yet available (exit status %d)", rebootExitStatus)
 		}
 
 		return nil
 	})
-	return err
 }
 
-// retryable will retry the given function over and over until a
-// non-error is returned, RestartTimeout expires, or ctx is
-// cancelled.
-func (p *Provisioner) retryable(ctx context.Context, f func(ctx context.Context) error) error {
-	return retry.Config{
-		RetryDelay:   func() time.Duration { return retryableDelay },
-		StartTimeout: p.config.RestartTimeout,
-	}.Run(ctx, f)
+// checkRestartComplete reports whether the machine has come back up
+// after a restart. It runs RestartCheckCommand if configured,
+// otherwise falls back to the shutdown.exe test-and-abort dance this
+// provisioner has always used.
+func (p *Provisioner) checkRestartComplete(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	if p.config.RestartCheckCommand != "" {
+		cmd := &packer.RemoteCmd{Command: p.config.RestartCheckCommand}
+		if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+			return err
+		}
+		if exitStatus := cmd.ExitStatus(); exitStatus != 0 {
+			return fmt.Errorf("Machine not yet available (exit status %d)", exitStatus)
+		}
+		return nil
+	}
+
+	cmd := &packer.RemoteCmd{Command: testRestartCommand}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+	if exitStatus := cmd.ExitStatus(); exitStatus != 0 {
+		return fmt.Errorf("Machine not yet available (exit status %d)", exitStatus)
+	}
+
+	cmd = &packer.RemoteCmd{Command: abortTestRestartCommand}
+	return cmd.RunWithUi(ctx, comm, ui)
+}
+
+// restartBackoff builds the backoff policy used while waiting for the
+// machine to restart, from the configured restart_backoff_* fields.
+func (p *Provisioner) restartBackoff() retry.Backoff {
+	return retry.Backoff{
+		Initial:    p.config.RestartBackoffInitial,
+		Max:        p.config.RestartBackoffMax,
+		Multiplier: p.config.RestartBackoffMultiplier,
+		Jitter:     p.config.RestartBackoffJitter,
+	}
 }
 
 func (p *Provisioner) windowsUpdateCommand() string {
-	return fmt.Sprintf(
-		"PowerShell -ExecutionPolicy Bypass -OutputFormat Text -EncodedCommand %s",
-		base64.StdEncoding.EncodeToString(
-			encodeUtf16Le(fmt.Sprintf(
-				"%s%s%s -UpdateLimit %d",
-				windowsUpdatePath,
-				searchCriteriaArgument(p.config.SearchCriteria),
-				filtersArgument(p.config.Filters),
-				p.config.UpdateLimit))))
+	return encodedPowerShellCommand(fmt.Sprintf(
+		"%s%s%s%s -UpdateLimit %d",
+		windowsUpdatePath,
+		searchCriteriaArgument(p.config.SearchCriteria),
+		filtersArgument(p.config.Filters),
+		skipKBsArgument(p.skipKBs),
+		p.config.UpdateLimit))
 }
 
 func (p *Provisioner) windowsUpdateCheckForRebootRequiredCommand() string {
+	return encodedPowerShellCommand(fmt.Sprintf(
+		"%s -OnlyCheckForRebootRequired",
+		windowsUpdatePath))
+}
+
+// encodedPowerShellCommand wraps a PowerShell script as a single
+// -EncodedCommand invocation, so it can be passed as a scheduled task
+// command without further quoting concerns.
+func encodedPowerShellCommand(script string) string {
 	return fmt.Sprintf(
 		"PowerShell -ExecutionPolicy Bypass -OutputFormat Text -EncodedCommand %s",
-		base64.StdEncoding.EncodeToString(
-			encodeUtf16Le(fmt.Sprintf(
-				"%s -OnlyCheckForRebootRequired",
-				windowsUpdatePath))))
+		base64.StdEncoding.EncodeToString(encodeUtf16Le(script)))
 }
 
 func encodeUtf16Le(s string) []byte {
@@ -310,6 +569,25 @@ func filtersArgument(filters []string) string {
 	return buffer.String()
 }
 
+func skipKBsArgument(skipKBs []string) string {
+	if len(skipKBs) == 0 {
+		return ""
+	}
+
+	var buffer bytes.Buffer
+
+	buffer.WriteString(" -SkipKBs ")
+
+	for i, kb := range skipKBs {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(escapePowerShellString(kb))
+	}
+
+	return buffer.String()
+}
+
 func escapePowerShellString(value string) string {
 	return fmt.Sprintf(
 		"'%s'",
@@ -0,0 +1,220 @@
+// Package report parses and renders the structured update results that
+// windows-update.ps1 emits after each install cycle, so that callers can
+// gate a build on which KBs actually landed instead of only the script's
+// overall exit status.
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// resultCodeSucceeded is the WUA OperationResultCode value
+// (IUpdateResult::ResultCode) for a successfully installed update.
+const resultCodeSucceeded = 2
+
+// Result describes the outcome of installing a single Windows update.
+type Result struct {
+	KBID            string `json:"kb_id"`
+	Title           string `json:"title"`
+	Category        string `json:"category"`
+	SizeBytes       int64  `json:"size_bytes"`
+	ResultCode      int    `json:"result_code"`
+	HResult         int32  `json:"hresult"`
+	InstallDuration int64  `json:"install_duration_ms"`
+	RequiresReboot  bool   `json:"requires_reboot"`
+}
+
+// Succeeded reports whether the update installed successfully.
+func (r Result) Succeeded() bool {
+	return r.ResultCode == resultCodeSucceeded
+}
+
+// Cycle is the set of results produced by a single update() invocation,
+// i.e. one pass of windows-update.ps1 before a potential reboot.
+type Cycle struct {
+	Results []Result `json:"results"`
+}
+
+// Report is the aggregation of every cycle run during a single
+// Provision call, merged across reboots.
+type Report struct {
+	Cycles []Cycle `json:"cycles"`
+}
+
+// ParseCycle decodes the JSON document windows-update.ps1 writes after
+// an install cycle.
+func ParseCycle(r io.Reader) (Cycle, error) {
+	var c Cycle
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return Cycle{}, fmt.Errorf("failed to parse update report: %s", err)
+	}
+	return c, nil
+}
+
+// Append merges a cycle's results into the aggregated report.
+func (rep *Report) Append(c Cycle) {
+	rep.Cycles = append(rep.Cycles, c)
+}
+
+// Failed returns every result across every cycle that did not install
+// successfully.
+func (rep *Report) Failed() []Result {
+	var failed []Result
+	for _, c := range rep.Cycles {
+		for _, r := range c.Results {
+			if !r.Succeeded() {
+				failed = append(failed, r)
+			}
+		}
+	}
+	return failed
+}
+
+// Failed returns the results of a single cycle that did not install
+// successfully.
+func (c Cycle) Failed() []Result {
+	var failed []Result
+	for _, r := range c.Results {
+		if !r.Succeeded() {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// FailedWithoutReboot returns the results of a single cycle that did
+// not install successfully and are not simply waiting on a pending
+// reboot to finish, i.e. a hard failure rather than "will resolve on
+// the next restart".
+func (c Cycle) FailedWithoutReboot() []Result {
+	var failed []Result
+	for _, r := range c.Results {
+		if !r.Succeeded() && !r.RequiresReboot {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// WriteFile renders the report in the given format ("json", "csv" or
+// "sarif") and writes it to path.
+func WriteFile(rep *Report, path string, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %s", err)
+	}
+	defer f.Close()
+
+	switch format {
+	case "", "json":
+		return writeJSON(f, rep)
+	case "csv":
+		return writeCSV(f, rep)
+	case "sarif":
+		return writeSARIF(f, rep)
+	default:
+		return fmt.Errorf("unknown report_format: %s", format)
+	}
+}
+
+func writeJSON(w io.Writer, rep *Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+func writeCSV(w io.Writer, rep *Report) error {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+
+	if err := cw.Write([]string{"kb_id", "title", "category", "size_bytes", "result_code", "hresult", "install_duration_ms", "requires_reboot"}); err != nil {
+		return err
+	}
+	for _, c := range rep.Cycles {
+		for _, r := range c.Results {
+			err := cw.Write([]string{
+				r.KBID,
+				r.Title,
+				r.Category,
+				strconv.FormatInt(r.SizeBytes, 10),
+				strconv.Itoa(r.ResultCode),
+				strconv.Itoa(int(r.HResult)),
+				strconv.FormatInt(r.InstallDuration, 10),
+				strconv.FormatBool(r.RequiresReboot),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sarifLog is a minimal SARIF 2.1.0 document: one run, one result per
+// update, with failed installs reported as errors and everything else
+// as notes.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	RuleID  string       `json:"ruleId"`
+	Level   string       `json:"level"`
+	Message sarifMessage `json:"message"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+func writeSARIF(w io.Writer, rep *Report) error {
+	log := sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{Driver: sarifDriver{Name: "packer-provisioner-windows-update"}},
+			},
+		},
+	}
+
+	for _, c := range rep.Cycles {
+		for _, r := range c.Results {
+			level := "note"
+			if !r.Succeeded() {
+				level = "error"
+			}
+			log.Runs[0].Results = append(log.Runs[0].Results, sarifResult{
+				RuleID: r.KBID,
+				Level:  level,
+				Message: sarifMessage{
+					Text: fmt.Sprintf("%s: result code %d (hresult 0x%x)", r.Title, r.ResultCode, uint32(r.HResult)),
+				},
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
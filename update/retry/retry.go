@@ -0,0 +1,98 @@
+// Package retry retries a possibly slow-to-succeed operation with an
+// exponential backoff and jitter, reporting each attempt to a
+// packer.Ui so long waits (e.g. for a cloud VM to finish rebooting)
+// don't look like a hang.
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/hashicorp/packer/packer"
+)
+
+// Backoff describes an exponential backoff-with-jitter policy.
+type Backoff struct {
+	// Initial is the delay before the second attempt. Defaults to 1s
+	// if zero.
+	Initial time.Duration
+
+	// Max caps the delay between attempts, before Jitter is added.
+	// Zero means unbounded.
+	Max time.Duration
+
+	// Multiplier is applied to the delay after each failed attempt.
+	// Defaults to 2 if it is <= 1.
+	Multiplier float64
+
+	// Jitter is the fraction (0-1) of the delay to add at random, so
+	// that many concurrent builds retrying the same operation don't
+	// all wake up at once.
+	Jitter float64
+}
+
+// Run calls f until it returns nil, ctx is cancelled, or timeout
+// elapses since Run was called (a zero timeout means no limit). It
+// sleeps between attempts according to the backoff policy, honoring
+// ctx cancellation during the sleep rather than only between
+// attempts.
+func (b Backoff) Run(ctx context.Context, ui packer.Ui, timeout time.Duration, f func(ctx context.Context) error) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	delay := b.Initial
+	if delay <= 0 {
+		delay = time.Second
+	}
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := f(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return err
+		}
+
+		sleep := b.jittered(delay)
+		ui.Say(fmt.Sprintf("attempt %d/∞ failed (%s), next retry in %s", attempt, err, sleep.Round(100*time.Millisecond)))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(sleep):
+		}
+
+		delay = b.next(delay)
+	}
+}
+
+func (b Backoff) next(delay time.Duration) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+
+	next := time.Duration(float64(delay) * multiplier)
+	if b.Max > 0 && next > b.Max {
+		next = b.Max
+	}
+	return next
+}
+
+func (b Backoff) jittered(delay time.Duration) time.Duration {
+	if b.Jitter <= 0 {
+		return delay
+	}
+	return delay + time.Duration(rand.Float64()*b.Jitter*float64(delay))
+}
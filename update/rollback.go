@@ -0,0 +1,117 @@
+package update
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/packer"
+
+	"github.com/ncelebic/packer-provisioner-windows-update/update/elevated"
+)
+
+// maxRollbackRetries bounds how many times a single update batch will
+// be reverted and retried with newly failing KBs added to the skip
+// list, so an update that keeps breaking the machine can't loop
+// forever.
+const maxRollbackRetries = 5
+
+// checkpoint snapshots the machine so it can be restored to its
+// current state if the upcoming update batch breaks it. It is a no-op
+// unless RollbackOnFailure is set.
+func (p *Provisioner) checkpoint(ctx context.Context, ui packer.Ui, comm packer.Communicator, batchID string) error {
+	if !p.config.RollbackOnFailure {
+		return nil
+	}
+
+	ui.Say(fmt.Sprintf("Creating rollback checkpoint %s...", batchID))
+
+	if p.config.RollbackProvider == "hyperv" {
+		return p.hyperVCheckpoint(batchID)
+	}
+	return p.systemRestoreCheckpoint(ctx, ui, comm, batchID)
+}
+
+// revert restores the machine to the checkpoint created for batchID.
+func (p *Provisioner) revert(ctx context.Context, ui packer.Ui, comm packer.Communicator, batchID string) error {
+	ui.Say(fmt.Sprintf("Reverting to rollback checkpoint %s...", batchID))
+
+	if p.config.RollbackProvider == "hyperv" {
+		return p.hyperVRevert(batchID)
+	}
+	return p.systemRestoreRevert(ctx, ui, comm, batchID)
+}
+
+// hyperVCheckpoint and hyperVRevert run locally, on the machine
+// running Packer, since a VM's checkpoints are a property of the
+// hypervisor host rather than something the guest can manage over the
+// communicator.
+
+func (p *Provisioner) hyperVCheckpoint(batchID string) error {
+	out, err := exec.Command("PowerShell", "-NoProfile", "-Command", fmt.Sprintf(
+		"Checkpoint-VM -Name %s -SnapshotName %s",
+		escapePowerShellString(p.config.HyperVVMName),
+		escapePowerShellString(batchID))).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to create Hyper-V checkpoint: %s: %s", err, out)
+	}
+	return nil
+}
+
+func (p *Provisioner) hyperVRevert(batchID string) error {
+	out, err := exec.Command("PowerShell", "-NoProfile", "-Command", fmt.Sprintf(
+		"Restore-VMSnapshot -VMName %s -Name %s -Confirm:$false",
+		escapePowerShellString(p.config.HyperVVMName),
+		escapePowerShellString(batchID))).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to revert Hyper-V checkpoint: %s: %s", err, out)
+	}
+	return nil
+}
+
+// systemRestoreCheckpoint and systemRestoreRevert run in-guest,
+// elevated, using the Windows System Restore feature and keying the
+// restore point description with batchID so it can be found again.
+
+func (p *Provisioner) systemRestoreCheckpoint(ctx context.Context, ui packer.Ui, comm packer.Communicator, batchID string) error {
+	command := encodedPowerShellCommand(fmt.Sprintf(
+		`Enable-ComputerRestore -Drive "C:\"; Checkpoint-Computer -Description %s -RestorePointType "MODIFY_SETTINGS"`,
+		escapePowerShellString(batchID)))
+
+	exitStatus, err := elevated.Run(ctx, comm, ui, elevated.Options{
+		Username:        p.config.Username,
+		Password:        p.config.Password,
+		TaskDescription: "Packer Windows update checkpoint task",
+		TaskName:        fmt.Sprintf("packer-windows-update-checkpoint-%s", uuid.TimeOrderedUUID()),
+		Command:         command,
+	})
+	if err != nil {
+		return err
+	}
+	if exitStatus != 0 {
+		return fmt.Errorf("failed to create System Restore checkpoint, exit status: %d", exitStatus)
+	}
+	return nil
+}
+
+func (p *Provisioner) systemRestoreRevert(ctx context.Context, ui packer.Ui, comm packer.Communicator, batchID string) error {
+	command := encodedPowerShellCommand(fmt.Sprintf(
+		`$rp = Get-ComputerRestorePoint | Where-Object { $_.Description -eq %s } | Select-Object -Last 1; if ($rp) { Restore-Computer -RestorePoint $rp.SequenceNumber -Confirm:$false } else { exit 1 }`,
+		escapePowerShellString(batchID)))
+
+	exitStatus, err := elevated.Run(ctx, comm, ui, elevated.Options{
+		Username:        p.config.Username,
+		Password:        p.config.Password,
+		TaskDescription: "Packer Windows update restore task",
+		TaskName:        fmt.Sprintf("packer-windows-update-revert-%s", uuid.TimeOrderedUUID()),
+		Command:         command,
+	})
+	if err != nil {
+		return err
+	}
+	if exitStatus != 0 {
+		return fmt.Errorf("failed to revert System Restore checkpoint, exit status: %d", exitStatus)
+	}
+	return nil
+}
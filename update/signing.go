@@ -0,0 +1,102 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/packer"
+
+	"github.com/ncelebic/packer-provisioner-windows-update/update/elevated"
+	"github.com/ncelebic/packer-provisioner-windows-update/update/verify"
+)
+
+// scriptSignaturePath is the name of the detached signature bundled
+// alongside windows-update.ps1.
+const scriptSignaturePath = "windows-update.ps1.sig"
+
+// remoteHashPath is where the elevated hash-verification task writes
+// the SHA-256 of the uploaded script for the provisioner to compare
+// against.
+const remoteHashPath = "C:/Windows/Temp/packer-windows-update.sha256"
+
+// requireSignedScripts reports whether windows-update.ps1 must be
+// verified before use. Defaults to true.
+func (p *Provisioner) requireSignedScripts() bool {
+	return p.config.RequireSignedScripts == nil || *p.config.RequireSignedScripts
+}
+
+// trustedPublicKeys returns the key bundled with the provisioner plus
+// any additional keys configured via TrustedPubKeys.
+func (p *Provisioner) trustedPublicKeys() ([]ed25519.PublicKey, error) {
+	defaultKey, err := verify.DefaultPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []ed25519.PublicKey{defaultKey}
+	for _, hexKey := range p.config.TrustedPubKeys {
+		key, err := verify.ParsePublicKey(hexKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted_pubkeys entry: %s", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// verifyScript checks script's detached signature against the
+// trusted public keys before it is uploaded anywhere.
+func (p *Provisioner) verifyScript(script []byte) error {
+	sig, err := verify.ParseSignature(string(MustAsset(scriptSignaturePath)))
+	if err != nil {
+		return err
+	}
+
+	keys, err := p.trustedPublicKeys()
+	if err != nil {
+		return err
+	}
+
+	return verify.Verify(script, sig, keys)
+}
+
+// verifyRemoteHash runs an elevated Get-FileHash over the uploaded
+// copy of script at remotePath and compares it to the local payload's
+// hash, to catch any tampering that happened in transit.
+func (p *Provisioner) verifyRemoteHash(ctx context.Context, ui packer.Ui, comm packer.Communicator, remotePath string, script []byte) error {
+	command := encodedPowerShellCommand(fmt.Sprintf(
+		"(Get-FileHash -Path %s -Algorithm SHA256).Hash.ToLower() | Set-Content -Path %s -NoNewline",
+		escapePowerShellString(remotePath), escapePowerShellString(remoteHashPath)))
+
+	exitStatus, err := elevated.Run(ctx, comm, ui, elevated.Options{
+		Username:        p.config.Username,
+		Password:        p.config.Password,
+		TaskDescription: "Packer Windows update hash verification task",
+		TaskName:        fmt.Sprintf("packer-windows-update-hash-%s", uuid.TimeOrderedUUID()),
+		Command:         command,
+	})
+	if err != nil {
+		return err
+	}
+	if exitStatus != 0 {
+		return fmt.Errorf("failed to hash uploaded script, exit status: %d", exitStatus)
+	}
+
+	var buffer bytes.Buffer
+	if err := comm.Download(remoteHashPath, &buffer); err != nil {
+		return fmt.Errorf("failed to download uploaded script hash: %s", err)
+	}
+
+	remoteHash := strings.TrimSpace(buffer.String())
+	expectedHash := verify.SHA256Hex(script)
+	if remoteHash != expectedHash {
+		return fmt.Errorf("uploaded script hash mismatch: expected %s, got %s (possible tampering in transit)", expectedHash, remoteHash)
+	}
+
+	return nil
+}
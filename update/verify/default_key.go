@@ -0,0 +1,20 @@
+package verify
+
+import (
+	"crypto/ed25519"
+	_ "embed"
+)
+
+// defaultPubKeyHex is the hex-encoded Ed25519 public key releases of
+// windows-update.ps1 are signed with. Operators who need to rotate or
+// replace it can add their own key via the trusted_pubkeys config
+// field instead of forking the provisioner.
+//
+//go:embed keys/release.pub
+var defaultPubKeyHex string
+
+// DefaultPublicKey returns the Ed25519 public key bundled with the
+// provisioner.
+func DefaultPublicKey() (ed25519.PublicKey, error) {
+	return ParsePublicKey(defaultPubKeyHex)
+}
@@ -0,0 +1,72 @@
+// Package verify checks the Authenticode-style detached signatures
+// carried alongside the PowerShell payloads this provisioner uploads,
+// so a tampered or unsigned script is rejected before it ever reaches
+// the remote host.
+//
+// Signatures are Ed25519 signatures (hex-encoded) over the SHA-256
+// digest of the payload, in the same spirit as the signify-style
+// detached signatures wireguard-windows verifies before trusting an
+// update.
+package verify
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Signature is a parsed detached signature.
+type Signature struct {
+	raw []byte
+}
+
+// ParseSignature decodes a hex-encoded Ed25519 signature.
+func ParseSignature(hexSig string) (Signature, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(hexSig))
+	if err != nil {
+		return Signature{}, fmt.Errorf("invalid signature encoding: %s", err)
+	}
+	if len(raw) != ed25519.SignatureSize {
+		return Signature{}, fmt.Errorf("invalid signature length: got %d bytes, want %d", len(raw), ed25519.SignatureSize)
+	}
+	return Signature{raw: raw}, nil
+}
+
+// ParsePublicKey decodes a hex-encoded Ed25519 public key.
+func ParsePublicKey(hexKey string) (ed25519.PublicKey, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(hexKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding: %s", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key length: got %d bytes, want %d", len(raw), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// Verify reports an error unless sig is a valid signature over the
+// SHA-256 digest of payload by at least one of trustedKeys.
+func Verify(payload []byte, sig Signature, trustedKeys []ed25519.PublicKey) error {
+	if len(trustedKeys) == 0 {
+		return fmt.Errorf("no trusted public keys configured")
+	}
+
+	digest := sha256.Sum256(payload)
+	for _, key := range trustedKeys {
+		if ed25519.Verify(key, digest[:], sig.raw) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("signature does not match any trusted public key")
+}
+
+// SHA256Hex returns the lowercase hex SHA-256 digest of payload. This
+// matches PowerShell's Get-FileHash output once that is lowercased
+// (Get-FileHash itself prints uppercase hex).
+func SHA256Hex(payload []byte) string {
+	digest := sha256.Sum256(payload)
+	return hex.EncodeToString(digest[:])
+}
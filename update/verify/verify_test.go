@@ -0,0 +1,78 @@
+package verify
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+// goldenPayload, goldenSignatureHex and goldenPubKeyHex were produced
+// with:
+//
+//	openssl genpkey -algorithm ed25519 -out key.pem
+//	openssl pkey -in key.pem -pubout -outform DER -out key.der
+//	printf '%s' "$payload" | openssl dgst -sha256 -binary -out payload.digest
+//	openssl pkeyutl -sign -inkey key.pem -rawin -in payload.digest -out payload.sig
+//
+// (Verify signs the SHA-256 digest of the payload, not the payload
+// itself, so the fixture signature must be produced over the digest.)
+const (
+	goldenPayload      = "Write-Host \"packer windows update\"\n"
+	goldenSignatureHex = "968cd426e177b593fc82d8825eb3d90a5ec74601b1d2a41fae042d015be2d9bb85d452e40bea13660c637b63a203db77a053d5608439bdafed97da23acd4280a"
+	goldenPubKeyHex    = "be39cd8281d16a6d0c5a841a8b73b6c17df4589e8a95ecb83d196ceb403c35e2"
+)
+
+func TestVerifyGoldenSignature(t *testing.T) {
+	sig, err := ParseSignature(goldenSignatureHex)
+	if err != nil {
+		t.Fatalf("ParseSignature: %s", err)
+	}
+
+	key, err := ParsePublicKey(goldenPubKeyHex)
+	if err != nil {
+		t.Fatalf("ParsePublicKey: %s", err)
+	}
+
+	if err := Verify([]byte(goldenPayload), sig, []ed25519.PublicKey{key}); err != nil {
+		t.Fatalf("Verify: %s", err)
+	}
+}
+
+func TestVerifyRejectsTamperedPayload(t *testing.T) {
+	sig, err := ParseSignature(goldenSignatureHex)
+	if err != nil {
+		t.Fatalf("ParseSignature: %s", err)
+	}
+
+	key, err := ParsePublicKey(goldenPubKeyHex)
+	if err != nil {
+		t.Fatalf("ParsePublicKey: %s", err)
+	}
+
+	tampered := goldenPayload + "# injected\n"
+	if err := Verify([]byte(tampered), sig, []ed25519.PublicKey{key}); err == nil {
+		t.Fatalf("expected Verify to reject a tampered payload, got nil error")
+	}
+}
+
+func TestVerifyRejectsUntrustedKey(t *testing.T) {
+	sig, err := ParseSignature(goldenSignatureHex)
+	if err != nil {
+		t.Fatalf("ParseSignature: %s", err)
+	}
+
+	// An unrelated, syntactically valid key that did not sign the payload.
+	otherKey, err := ParsePublicKey("0000000000000000000000000000000000000000000000000000000000000000")
+	if err != nil {
+		t.Fatalf("ParsePublicKey: %s", err)
+	}
+
+	if err := Verify([]byte(goldenPayload), sig, []ed25519.PublicKey{otherKey}); err == nil {
+		t.Fatalf("expected Verify to reject an untrusted key, got nil error")
+	}
+}
+
+func TestDefaultPublicKeyParses(t *testing.T) {
+	if _, err := DefaultPublicKey(); err != nil {
+		t.Fatalf("DefaultPublicKey: %s", err)
+	}
+}
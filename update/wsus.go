@@ -0,0 +1,154 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/packer/common/uuid"
+	"github.com/hashicorp/packer/packer"
+
+	"github.com/ncelebic/packer-provisioner-windows-update/update/elevated"
+)
+
+const (
+	wsusBackupPath  = "C:/Windows/Temp/packer-windows-update-wsus-backup.json"
+	wsusPolicyKey   = `HKLM:\SOFTWARE\Policies\Microsoft\Windows\WindowsUpdate`
+	wsusAUPolicyKey = `HKLM:\SOFTWARE\Policies\Microsoft\Windows\WindowsUpdate\AU`
+)
+
+// wsusConfigured reports whether the user asked the provisioner to
+// point Windows Update at an internal WSUS server, or to disable
+// Windows Update access entirely.
+func (p *Provisioner) wsusConfigured() bool {
+	return p.config.WSUSServer != "" || p.config.DisableWindowsUpdateAccess
+}
+
+// configureWSUS points Windows Update at the configured WSUS server
+// (or disables Windows Update access entirely), backing up the prior
+// policy values on the remote host to wsusBackupPath so restoreWSUS
+// can put them back afterwards.
+func (p *Provisioner) configureWSUS(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	ui.Say("Configuring Windows Update policy...")
+
+	if err := p.runElevatedWSUSScript(ctx, ui, comm, "Packer Windows update WSUS configuration task", "wsus-configure", p.wsusConfigureCommand()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// restoreWSUS restores the Windows Update policy values that were in
+// place before configureWSUS ran.
+func (p *Provisioner) restoreWSUS(ctx context.Context, ui packer.Ui, comm packer.Communicator) error {
+	ui.Say("Restoring previous Windows Update policy...")
+
+	return p.runElevatedWSUSScript(ctx, ui, comm, "Packer Windows update WSUS restore task", "wsus-restore", p.wsusRestoreCommand())
+}
+
+func (p *Provisioner) runElevatedWSUSScript(ctx context.Context, ui packer.Ui, comm packer.Communicator, taskDescription string, taskSuffix string, command string) error {
+	exitStatus, err := elevated.Run(ctx, comm, ui, elevated.Options{
+		Username:        p.config.Username,
+		Password:        p.config.Password,
+		TaskDescription: taskDescription,
+		TaskName:        fmt.Sprintf("packer-windows-update-%s-%s", taskSuffix, uuid.TimeOrderedUUID()),
+		Command:         command,
+	})
+	if err != nil {
+		return err
+	}
+	if exitStatus != 0 {
+		return fmt.Errorf("WSUS policy script exited with non-zero exit status: %d", exitStatus)
+	}
+
+	return nil
+}
+
+func (p *Provisioner) wsusConfigureCommand() string {
+	return encodedPowerShellCommand(fmt.Sprintf(`
+$ErrorActionPreference = "Stop"
+$policyKey = '%s'
+$auKey = '%s'
+New-Item -Path $policyKey -Force | Out-Null
+New-Item -Path $auKey -Force | Out-Null
+
+$backup = @{
+  WUServer = (Get-ItemProperty -Path $policyKey -Name WUServer -ErrorAction SilentlyContinue).WUServer
+  WUStatusServer = (Get-ItemProperty -Path $policyKey -Name WUStatusServer -ErrorAction SilentlyContinue).WUStatusServer
+  TargetGroup = (Get-ItemProperty -Path $policyKey -Name TargetGroup -ErrorAction SilentlyContinue).TargetGroup
+  TargetGroupEnabled = (Get-ItemProperty -Path $policyKey -Name TargetGroupEnabled -ErrorAction SilentlyContinue).TargetGroupEnabled
+  UseWUServer = (Get-ItemProperty -Path $auKey -Name UseWUServer -ErrorAction SilentlyContinue).UseWUServer
+  DisableWindowsUpdateAccess = (Get-ItemProperty -Path $policyKey -Name DisableWindowsUpdateAccess -ErrorAction SilentlyContinue).DisableWindowsUpdateAccess
+}
+$backup | ConvertTo-Json | Set-Content -Path '%s'
+
+%s
+
+Restart-Service -Name wuauserv -Force
+`,
+		wsusPolicyKey, wsusAUPolicyKey, wsusBackupPath, p.wsusSetValuesSnippet()))
+}
+
+// wsusSetValuesSnippet builds the Set-ItemProperty calls for whichever
+// WSUS fields the user configured.
+func (p *Provisioner) wsusSetValuesSnippet() string {
+	var buffer bytes.Buffer
+
+	if p.config.WSUSServer != "" {
+		statusServer := p.config.WSUSStatusServer
+		if statusServer == "" {
+			statusServer = p.config.WSUSServer
+		}
+		fmt.Fprintf(&buffer, "Set-ItemProperty -Path $policyKey -Name WUServer -Value %s\n", escapePowerShellString(p.config.WSUSServer))
+		fmt.Fprintf(&buffer, "Set-ItemProperty -Path $policyKey -Name WUStatusServer -Value %s\n", escapePowerShellString(statusServer))
+		fmt.Fprintf(&buffer, "Set-ItemProperty -Path $auKey -Name UseWUServer -Value 1\n")
+	}
+
+	if p.config.WSUSTargetGroup != "" {
+		fmt.Fprintf(&buffer, "Set-ItemProperty -Path $policyKey -Name TargetGroup -Value %s\n", escapePowerShellString(p.config.WSUSTargetGroup))
+		fmt.Fprintf(&buffer, "Set-ItemProperty -Path $policyKey -Name TargetGroupEnabled -Value 1\n")
+	}
+
+	if p.config.UseMicrosoftUpdate {
+		fmt.Fprintf(&buffer, "Set-ItemProperty -Path $policyKey -Name DisableWindowsUpdateAccess -Value 0\n")
+	}
+
+	if p.config.DisableWindowsUpdateAccess {
+		fmt.Fprintf(&buffer, "Set-ItemProperty -Path $policyKey -Name DisableWindowsUpdateAccess -Value 1\n")
+	}
+
+	return buffer.String()
+}
+
+func (p *Provisioner) wsusRestoreCommand() string {
+	return encodedPowerShellCommand(fmt.Sprintf(`
+$ErrorActionPreference = "Stop"
+$policyKey = '%s'
+$auKey = '%s'
+$backupPath = '%s'
+
+if (Test-Path $backupPath) {
+  $backup = Get-Content -Path $backupPath | ConvertFrom-Json
+
+  function Restore-Value($path, $name, $value) {
+    if ($null -eq $value) {
+      Remove-ItemProperty -Path $path -Name $name -ErrorAction SilentlyContinue
+    } else {
+      Set-ItemProperty -Path $path -Name $name -Value $value
+    }
+  }
+
+  Restore-Value $policyKey 'WUServer' $backup.WUServer
+  Restore-Value $policyKey 'WUStatusServer' $backup.WUStatusServer
+  Restore-Value $policyKey 'TargetGroup' $backup.TargetGroup
+  Restore-Value $policyKey 'TargetGroupEnabled' $backup.TargetGroupEnabled
+  Restore-Value $auKey 'UseWUServer' $backup.UseWUServer
+  Restore-Value $policyKey 'DisableWindowsUpdateAccess' $backup.DisableWindowsUpdateAccess
+
+  Remove-Item -Path $backupPath -Force -ErrorAction SilentlyContinue
+}
+
+Restart-Service -Name wuauserv -Force
+`,
+		wsusPolicyKey, wsusAUPolicyKey, wsusBackupPath))
+}